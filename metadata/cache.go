@@ -0,0 +1,387 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CacheOptions configures a CachingClient.
+type CacheOptions struct {
+	// TTL is how long a cached response stays fresh. Defaults to 5 seconds.
+	TTL time.Duration
+	// Watch, if set, makes the CachingClient start a background watcher on
+	// the wrapped Client and invalidate its cache whenever the metadata
+	// version changes, instead of relying solely on TTL expiry.
+	Watch *WatchOptions
+	// Context bounds the lifetime of the background watcher started for
+	// Watch. Defaults to context.Background() (the watcher then runs for
+	// the lifetime of the process).
+	Context context.Context
+	// Observer, if set, receives CacheHit/CacheMiss events for every
+	// SendRequest call.
+	Observer Observer
+}
+
+type cacheEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// cacheCall represents a SendRequest that is in flight for a given path, so
+// concurrent callers for the same path wait on a single underlying request
+// instead of stampeding the metadata service.
+type cacheCall struct {
+	done  chan struct{}
+	value []byte
+	err   error
+}
+
+// CachingClient wraps a Client and memoizes SendRequest responses by path,
+// with TTL expiry and single-flight deduplication of concurrent misses.
+type CachingClient struct {
+	Client
+
+	ttl      time.Duration
+	observer Observer
+
+	mu       sync.Mutex
+	entries  map[string]cacheEntry
+	inflight map[string]*cacheCall
+}
+
+// NewCachingClient wraps inner so that repeated calls for the same metadata
+// path are served from an in-memory cache instead of issuing a fresh HTTP
+// request every time.
+func NewCachingClient(inner Client, opts CacheOptions) Client {
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = 5 * time.Second
+	}
+
+	observer := opts.Observer
+	if observer == nil {
+		observer = NopObserver{}
+	}
+
+	cc := &CachingClient{
+		Client:   inner,
+		ttl:      ttl,
+		observer: observer,
+		entries:  map[string]cacheEntry{},
+		inflight: map[string]*cacheCall{},
+	}
+
+	if opts.Watch != nil {
+		ctx := opts.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		go inner.OnChangeWithContext(ctx, *opts.Watch, func(ChangeEvent) {
+			cc.Invalidate()
+		})
+	}
+
+	return cc
+}
+
+// Invalidate drops every cached response, forcing the next request for each
+// path to hit the wrapped Client again.
+func (c *CachingClient) Invalidate() {
+	c.mu.Lock()
+	c.entries = map[string]cacheEntry{}
+	c.mu.Unlock()
+}
+
+// SendRequest serves path from cache when fresh, otherwise fetches it from
+// the wrapped Client, deduplicating concurrent fetches for the same path.
+func (c *CachingClient) SendRequest(path string) ([]byte, error) {
+	return c.SendRequestWithContext(context.Background(), path)
+}
+
+// SendRequestWithContext behaves like SendRequest but ties a cache miss's
+// underlying fetch, and the wait for an in-flight one, to ctx.
+func (c *CachingClient) SendRequestWithContext(ctx context.Context, path string) ([]byte, error) {
+	c.mu.Lock()
+	if entry, ok := c.entries[path]; ok && time.Now().Before(entry.expires) {
+		c.mu.Unlock()
+		c.observer.CacheHit(path)
+		return entry.value, nil
+	}
+	if call, ok := c.inflight[path]; ok {
+		c.mu.Unlock()
+		c.observer.CacheMiss(path)
+		select {
+		case <-call.done:
+			return call.value, call.err
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+	c.observer.CacheMiss(path)
+
+	call := &cacheCall{done: make(chan struct{})}
+	c.inflight[path] = call
+	c.mu.Unlock()
+
+	value, err := c.Client.SendRequestWithContext(ctx, path)
+	call.value, call.err = value, err
+	close(call.done)
+
+	c.mu.Lock()
+	delete(c.inflight, path)
+	if err == nil {
+		c.entries[path] = cacheEntry{value: value, expires: time.Now().Add(c.ttl)}
+	}
+	c.mu.Unlock()
+
+	return value, err
+}
+
+func (c *CachingClient) GetRegionName() (string, error) {
+	resp, err := c.SendRequest("/region_name")
+	if err != nil {
+		return "", err
+	}
+	regionName := string(resp[:])
+	regionName = strings.TrimSuffix(regionName, "\"")
+	regionName = strings.TrimPrefix(regionName, "\"")
+	return regionName, nil
+}
+
+func (c *CachingClient) GetHosts() ([]Host, error) {
+	resp, err := c.SendRequest("/hosts")
+	var hosts []Host
+	if err != nil {
+		return hosts, err
+	}
+	if err = json.Unmarshal(resp, &hosts); err != nil {
+		return hosts, err
+	}
+	return hosts, nil
+}
+
+func (c *CachingClient) GetHost(UUID string) (Host, error) {
+	var host Host
+	hosts, err := c.GetHosts()
+	if err != nil {
+		return host, err
+	}
+	for _, h := range hosts {
+		if h.UUID == UUID {
+			return h, nil
+		}
+	}
+	return host, fmt.Errorf("%w: host %v", ErrNotFound, UUID)
+}
+
+func (c *CachingClient) GetSelfHost() (Host, error) {
+	resp, err := c.SendRequest("/self/host")
+	var host Host
+	if err != nil {
+		return host, err
+	}
+	if err = json.Unmarshal(resp, &host); err != nil {
+		return host, err
+	}
+	return host, nil
+}
+
+func (c *CachingClient) GetSelfContainer() (Container, error) {
+	resp, err := c.SendRequest("/self/container")
+	var container Container
+	if err != nil {
+		return container, err
+	}
+	if err = json.Unmarshal(resp, &container); err != nil {
+		return container, err
+	}
+	return container, nil
+}
+
+func (c *CachingClient) GetSelfServiceByName(name string) (Service, error) {
+	resp, err := c.SendRequest("/self/stack/services/" + name)
+	var service Service
+	if err != nil {
+		return service, err
+	}
+	if err = json.Unmarshal(resp, &service); err != nil {
+		return service, err
+	}
+	return service, nil
+}
+
+func (c *CachingClient) GetSelfService() (Service, error) {
+	resp, err := c.SendRequest("/self/service")
+	var service Service
+	if err != nil {
+		return service, err
+	}
+	if err = json.Unmarshal(resp, &service); err != nil {
+		return service, err
+	}
+	return service, nil
+}
+
+func (c *CachingClient) GetSelfStack() (Stack, error) {
+	resp, err := c.SendRequest("/self/stack")
+	var stack Stack
+	if err != nil {
+		return stack, err
+	}
+	if err = json.Unmarshal(resp, &stack); err != nil {
+		return stack, err
+	}
+	return stack, nil
+}
+
+func (c *CachingClient) GetServiceByName(stackName string, svcName string) (Service, error) {
+	resp, err := c.SendRequest("/stacks/" + stackName + "/services/" + svcName)
+	var service Service
+	if err != nil {
+		return service, err
+	}
+	if err = json.Unmarshal(resp, &service); err != nil {
+		return service, err
+	}
+	return service, nil
+}
+
+func (c *CachingClient) GetServices() ([]Service, error) {
+	resp, err := c.SendRequest("/services")
+	var services []Service
+	if err != nil {
+		return services, err
+	}
+	if err = json.Unmarshal(resp, &services); err != nil {
+		return services, err
+	}
+	return services, nil
+}
+
+func (c *CachingClient) GetStacks() ([]Stack, error) {
+	resp, err := c.SendRequest("/stacks")
+	var stacks []Stack
+	if err != nil {
+		return stacks, err
+	}
+	if err = json.Unmarshal(resp, &stacks); err != nil {
+		return stacks, err
+	}
+	return stacks, nil
+}
+
+func (c *CachingClient) GetStackByName(name string) (Stack, error) {
+	resp, err := c.SendRequest("/stacks/" + name)
+	var stack Stack
+	if err != nil {
+		return stack, err
+	}
+	if err = json.Unmarshal(resp, &stack); err != nil {
+		return stack, err
+	}
+	return stack, nil
+}
+
+func (c *CachingClient) GetNetworks() ([]Network, error) {
+	resp, err := c.SendRequest("/networks")
+	var networks []Network
+	if err != nil {
+		return networks, err
+	}
+	if err = json.Unmarshal(resp, &networks); err != nil {
+		return networks, err
+	}
+	return networks, nil
+}
+
+func (c *CachingClient) GetContainers() ([]Container, error) {
+	resp, err := c.SendRequest("/containers")
+	var containers []Container
+	if err != nil {
+		return containers, err
+	}
+	if err = json.Unmarshal(resp, &containers); err != nil {
+		return containers, err
+	}
+	return containers, nil
+}
+
+func (c *CachingClient) GetServiceContainers(serviceName string, stackName string) ([]Container, error) {
+	var serviceContainers = []Container{}
+	containers, err := c.GetContainers()
+	if err != nil {
+		return serviceContainers, err
+	}
+	for _, container := range containers {
+		if container.StackName == stackName && container.ServiceName == serviceName {
+			serviceContainers = append(serviceContainers, container)
+		}
+	}
+	return serviceContainers, nil
+}
+
+func (c *CachingClient) getEnvironments() ([]Environment, error) {
+	resp, err := c.SendRequest("/environments")
+	var environments []Environment
+	if err != nil {
+		return environments, err
+	}
+	if err = json.Unmarshal(resp, &environments); err != nil {
+		return environments, err
+	}
+	return environments, nil
+}
+
+func (c *CachingClient) GetServiceByRegionEnvironment(regionName string, envName string, stackName string, svcName string) (Service, error) {
+	var service Service
+	environments, err := c.getEnvironments()
+	if err != nil {
+		return service, err
+	}
+	for _, env := range environments {
+		if regionName == env.RegionName && envName == env.Name {
+			for _, svc := range env.Services {
+				if stackName == svc.StackName && svcName == svc.Name {
+					return svc, nil
+				}
+			}
+		}
+	}
+	return service, nil
+}
+
+func (c *CachingClient) GetServiceByEnvironment(envName string, stackName string, svcName string) (Service, error) {
+	regionName, err := c.GetRegionName()
+	var service Service
+	if err != nil {
+		return service, err
+	}
+	return c.GetServiceByRegionEnvironment(regionName, envName, stackName, svcName)
+}
+
+func (c *CachingClient) GetServicesByRegionEnvironment(regionName string, envName string) ([]Service, error) {
+	var services []Service
+	environments, err := c.getEnvironments()
+	if err != nil {
+		return services, err
+	}
+	for _, env := range environments {
+		if regionName == env.RegionName && envName == env.Name {
+			services = append(services, env.Services...)
+		}
+	}
+	return services, nil
+}
+
+func (c *CachingClient) GetServicesByEnvironment(envName string) ([]Service, error) {
+	regionName, err := c.GetRegionName()
+	var services []Service
+	if err != nil {
+		return services, err
+	}
+	return c.GetServicesByRegionEnvironment(regionName, envName)
+}
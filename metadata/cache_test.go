@@ -0,0 +1,198 @@
+package metadata_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+	"github.com/leodotcloud/go-rancher-metadata/metadatatest"
+)
+
+// countingObserver counts CacheHit/CacheMiss calls so tests can assert on
+// cache behavior without instrumenting the fake server itself.
+type countingObserver struct {
+	metadata.NopObserver
+	hits, misses int
+}
+
+func (o *countingObserver) CacheHit(string)  { o.hits++ }
+func (o *countingObserver) CacheMiss(string) { o.misses++ }
+
+// requestCountingObserver counts RequestStarted calls per path, so tests can
+// assert on how many times the wrapped Client actually hit the fake server,
+// independent of the CachingClient's own CacheHit/CacheMiss bookkeeping.
+type requestCountingObserver struct {
+	metadata.NopObserver
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+func newRequestCountingObserver() *requestCountingObserver {
+	return &requestCountingObserver{counts: map[string]int{}}
+}
+
+func (o *requestCountingObserver) RequestStarted(path string) {
+	o.mu.Lock()
+	o.counts[path]++
+	o.mu.Unlock()
+}
+
+func (o *requestCountingObserver) count(path string) int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.counts[path]
+}
+
+// TestCachingClientCoversSendRequestWithContext verifies that
+// SendRequestWithContext, the path SendRequestWithContext-based callers
+// such as GetStacks now use, is served from cache like SendRequest is,
+// instead of bypassing the cache by dispatching straight to the embedded
+// Client.
+func TestCachingClientCoversSendRequestWithContext(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Stacks: []metadata.Stack{{Name: "stack1"}},
+	})
+	defer server.Close()
+
+	inner := metadata.NewClient(server.URL())
+	observer := &countingObserver{}
+	cached := metadata.NewCachingClient(inner, metadata.CacheOptions{
+		TTL:      time.Minute,
+		Observer: observer,
+	})
+
+	if _, err := cached.SendRequestWithContext(context.Background(), "/stacks"); err != nil {
+		t.Fatalf("SendRequestWithContext: %v", err)
+	}
+	if _, err := cached.GetStacks(); err != nil {
+		t.Fatalf("GetStacks: %v", err)
+	}
+
+	if observer.misses != 1 {
+		t.Fatalf("misses = %d, want 1 (GetStacks should have hit the cache SendRequestWithContext primed)", observer.misses)
+	}
+	if observer.hits != 1 {
+		t.Fatalf("hits = %d, want 1", observer.hits)
+	}
+}
+
+// TestCachingClientDeduplicatesConcurrentMisses verifies that N concurrent
+// callers for the same uncached path collapse into a single request against
+// the wrapped Client, instead of all stampeding the metadata service.
+func TestCachingClientDeduplicatesConcurrentMisses(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Stacks: []metadata.Stack{{Name: "stack1"}},
+	})
+	defer server.Close()
+
+	requests := newRequestCountingObserver()
+	inner := metadata.NewClient(server.URL(), metadata.WithObserver(requests))
+	cached := metadata.NewCachingClient(inner, metadata.CacheOptions{TTL: time.Minute})
+
+	const n = 20
+	var wg sync.WaitGroup
+	wg.Add(n)
+	for i := 0; i < n; i++ {
+		go func() {
+			defer wg.Done()
+			if _, err := cached.SendRequest("/stacks"); err != nil {
+				t.Errorf("SendRequest: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := requests.count("/stacks"); got != 1 {
+		t.Fatalf("wrapped Client saw %d requests for /stacks, want 1 (concurrent misses should be deduplicated)", got)
+	}
+}
+
+// TestCachingClientWatchInvalidatesOnChange verifies that a CachingClient
+// built with CacheOptions.Watch drops its cached entries as soon as the
+// metadata version changes, instead of waiting out the TTL.
+func TestCachingClientWatchInvalidatesOnChange(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Version: "1",
+		Stacks:  []metadata.Stack{{Name: "stack1"}},
+	})
+	defer server.Close()
+
+	requests := newRequestCountingObserver()
+	inner := metadata.NewClient(server.URL(), metadata.WithObserver(requests))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cached := metadata.NewCachingClient(inner, metadata.CacheOptions{
+		TTL: time.Hour, // long enough that only Watch invalidation can explain a second fetch
+		Watch: &metadata.WatchOptions{
+			Mode:     metadata.Interval,
+			Interval: 10 * time.Millisecond,
+		},
+		Context: ctx,
+	})
+
+	if _, err := cached.SendRequest("/stacks"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if got := requests.count("/stacks"); got != 1 {
+		t.Fatalf("wrapped Client saw %d requests for /stacks before publish, want 1", got)
+	}
+
+	server.Publish(metadatatest.Snapshot{
+		Version: "2",
+		Stacks:  []metadata.Stack{{Name: "stack1"}},
+	})
+
+	deadline := time.After(time.Second)
+	for {
+		if _, err := cached.SendRequest("/stacks"); err != nil {
+			t.Fatalf("SendRequest: %v", err)
+		}
+		if requests.count("/stacks") > 1 {
+			break
+		}
+		select {
+		case <-deadline:
+			t.Fatal("cache was never invalidated after server.Publish bumped the version")
+		case <-time.After(5 * time.Millisecond):
+		}
+	}
+}
+
+// TestCachingClientDerivedLookupsUseCache verifies that GetHost,
+// GetServiceContainers and GetServiceByRegionEnvironment -- lookups derived
+// from /hosts, /containers and /environments -- are served from the cached
+// fetch on a second call instead of re-hitting the wrapped Client.
+func TestCachingClientDerivedLookupsUseCache(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Hosts:      []metadata.Host{{UUID: "host1"}},
+		Containers: []metadata.Container{{UUID: "c1", StackName: "stack1", ServiceName: "svc1"}},
+		Environments: []metadata.Environment{
+			{Name: "env1", RegionName: "region1", Services: []metadata.Service{{Name: "svc1", StackName: "stack1"}}},
+		},
+	})
+	defer server.Close()
+
+	requests := newRequestCountingObserver()
+	inner := metadata.NewClient(server.URL(), metadata.WithObserver(requests))
+	cached := metadata.NewCachingClient(inner, metadata.CacheOptions{TTL: time.Minute})
+
+	for i := 0; i < 2; i++ {
+		if _, err := cached.GetHost("host1"); err != nil {
+			t.Fatalf("GetHost: %v", err)
+		}
+		if _, err := cached.GetServiceContainers("svc1", "stack1"); err != nil {
+			t.Fatalf("GetServiceContainers: %v", err)
+		}
+		if _, err := cached.GetServiceByRegionEnvironment("region1", "env1", "stack1", "svc1"); err != nil {
+			t.Fatalf("GetServiceByRegionEnvironment: %v", err)
+		}
+	}
+
+	for path, want := range map[string]int{"/hosts": 1, "/containers": 1, "/environments": 1} {
+		if got := requests.count(path); got != want {
+			t.Errorf("wrapped Client saw %d requests for %s across 2 lookups, want %d (derived lookups should share the cache)", got, path, want)
+		}
+	}
+}
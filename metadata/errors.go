@@ -0,0 +1,113 @@
+package metadata
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// StatusError is returned by SendRequest when the metadata service responds
+// with a non-200 status. Use errors.Is against ErrNotFound, ErrUnauthorized
+// or ErrServerUnavailable rather than comparing StatusCode directly.
+type StatusError struct {
+	StatusCode int
+	Path       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Error %v accessing %v path", e.StatusCode, e.Path)
+}
+
+var (
+	// ErrNotFound is returned when the metadata service responds 404, or
+	// when a derived lookup such as GetHost can't find a matching entry.
+	ErrNotFound = errors.New("metadata: not found")
+	// ErrUnauthorized is returned when the metadata service responds 401 or 403.
+	ErrUnauthorized = errors.New("metadata: unauthorized")
+	// ErrServerUnavailable is returned when the metadata service responds with a 5xx status.
+	ErrServerUnavailable = errors.New("metadata: server unavailable")
+	// ErrTimeout is returned when the request to the metadata service times out.
+	ErrTimeout = errors.New("metadata: timeout")
+	// ErrConnection is returned when a GET fails before a response is
+	// received for a reason other than a timeout, such as a connection
+	// being refused or reset, or the server closing the connection early.
+	ErrConnection = errors.New("metadata: connection error")
+)
+
+// classify rewrites err, when recognized, so that callers can use
+// errors.Is(err, metadata.ErrNotFound) instead of inspecting status codes.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch {
+		case statusErr.StatusCode == http.StatusNotFound:
+			return fmt.Errorf("%w: %v", ErrNotFound, err)
+		case statusErr.StatusCode == http.StatusUnauthorized, statusErr.StatusCode == http.StatusForbidden:
+			return fmt.Errorf("%w: %v", ErrUnauthorized, err)
+		case statusErr.StatusCode >= 500:
+			return fmt.Errorf("%w: %v", ErrServerUnavailable, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		if netErr.Timeout() {
+			return fmt.Errorf("%w: %v", ErrTimeout, err)
+		}
+		return fmt.Errorf("%w: %v", ErrConnection, err)
+	}
+
+	return err
+}
+
+func isRetryable(err error) bool {
+	return errors.Is(err, ErrServerUnavailable) || errors.Is(err, ErrTimeout) || errors.Is(err, ErrConnection)
+}
+
+// RetryPolicy controls how SendRequest retries a GET after a retryable
+// error (a 5xx response or a network error: timeout, refused or reset
+// connection).
+type RetryPolicy struct {
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+	Jitter      bool
+}
+
+func defaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    5 * time.Second,
+		Jitter:      true,
+	}
+}
+
+func (p RetryPolicy) delay(attempt int) time.Duration {
+	base := p.BaseDelay
+	if base <= 0 {
+		base = 200 * time.Millisecond
+	}
+	max := p.MaxDelay
+	if max <= 0 {
+		max = 5 * time.Second
+	}
+
+	d := float64(base) * math.Pow(2, float64(attempt-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	if p.Jitter {
+		d *= 0.5 + rand.Float64()*0.5
+	}
+	return time.Duration(d)
+}
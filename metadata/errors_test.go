@@ -0,0 +1,91 @@
+package metadata_test
+
+import (
+	"errors"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+	"github.com/leodotcloud/go-rancher-metadata/metadatatest"
+)
+
+type attemptCountingObserver struct {
+	metadata.NopObserver
+	attempts int32
+}
+
+func (o *attemptCountingObserver) RequestStarted(string) {
+	atomic.AddInt32(&o.attempts, 1)
+}
+
+// TestSendRequestRetriesConnectionReset guards against a plain connection
+// refused error (a net.Error that isn't a timeout) falling through classify
+// unclassified and isRetryable giving up after a single attempt.
+func TestSendRequestRetriesConnectionReset(t *testing.T) {
+	server := httptest.NewServer(nil)
+	url := server.URL
+	server.Close() // requests to url now fail with "connection refused"
+
+	observer := &attemptCountingObserver{}
+	client := metadata.NewClient(url,
+		metadata.WithObserver(observer),
+		metadata.WithRetryPolicy(metadata.RetryPolicy{
+			MaxAttempts: 3,
+			BaseDelay:   10 * time.Millisecond,
+			MaxDelay:    10 * time.Millisecond,
+		}),
+	)
+
+	_, err := client.SendRequest("/version")
+	if err == nil {
+		t.Fatal("SendRequest against a closed server unexpectedly succeeded")
+	}
+	if !errors.Is(err, metadata.ErrConnection) {
+		t.Fatalf("SendRequest error = %v, want it to wrap ErrConnection", err)
+	}
+	if got := atomic.LoadInt32(&observer.attempts); got != 3 {
+		t.Fatalf("RequestStarted called %d times, want 3 (isRetryable should treat a refused connection as retryable)", got)
+	}
+}
+
+// TestGetStackByNameNotFound verifies that a 404 for a missing stack
+// classifies to ErrNotFound, so callers can use errors.Is instead of
+// matching on status codes or error strings.
+func TestGetStackByNameNotFound(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Stacks: []metadata.Stack{{Name: "present"}},
+	})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL())
+
+	_, err := client.GetStackByName("missing")
+	if err == nil {
+		t.Fatal("GetStackByName for a missing stack unexpectedly succeeded")
+	}
+	if !errors.Is(err, metadata.ErrNotFound) {
+		t.Fatalf("GetStackByName error = %v, want it to wrap ErrNotFound", err)
+	}
+}
+
+// TestGetHostNotFound verifies that GetHost classifies an unknown UUID as
+// ErrNotFound, mirroring GetStackByName, even though /hosts itself always
+// returns 200.
+func TestGetHostNotFound(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		Hosts: []metadata.Host{{UUID: "present"}},
+	})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL())
+
+	_, err := client.GetHost("missing")
+	if err == nil {
+		t.Fatal("GetHost for an unknown UUID unexpectedly succeeded")
+	}
+	if !errors.Is(err, metadata.ErrNotFound) {
+		t.Fatalf("GetHost error = %v, want it to wrap ErrNotFound", err)
+	}
+}
@@ -1,9 +1,11 @@
 package metadata
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"log"
 	"net/http"
 	"strings"
 	"time"
@@ -12,7 +14,9 @@ import (
 type Client interface {
 	OnChangeWithError(int, func(string)) error
 	OnChange(int, func(string))
+	OnChangeWithContext(context.Context, WatchOptions, func(ChangeEvent)) error
 	SendRequest(string) ([]byte, error)
+	SendRequestWithContext(context.Context, string) ([]byte, error)
 	GetVersion() (string, error)
 	GetRegionName() (string, error)
 	GetSelfHost() (Host, error)
@@ -36,22 +40,41 @@ type Client interface {
 }
 
 type client struct {
-	url    string
-	ip     string
-	client *http.Client
+	url         string
+	ip          string
+	client      *http.Client
+	userClient  bool
+	retryPolicy RetryPolicy
+	headers     http.Header
+	userAgent   string
+	logger      *log.Logger
+	baseCtx     context.Context
+	observer    Observer
 }
 
-func newClient(url, ip string) *client {
-	return &client{url, ip, &http.Client{Timeout: 10 * time.Second}}
+func newClient(url string, opts ...Option) *client {
+	c := &client{
+		url:         url,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		retryPolicy: defaultRetryPolicy(),
+		headers:     http.Header{},
+		observer:    NopObserver{},
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
 }
 
-func NewClient(url string) Client {
-	ip := ""
-	return newClient(url, ip)
+// NewClient builds a Client for the metadata service at url, configured by
+// the given options. With no options it behaves exactly as before: a
+// 10-second timeout, no extra headers, and a default retry policy.
+func NewClient(url string, opts ...Option) Client {
+	return newClient(url, opts...)
 }
 
 func NewClientWithIPAndWait(url, ip string) (Client, error) {
-	client := newClient(url, ip)
+	client := newClient(url, WithForwardedFor(ip))
 
 	if err := testConnection(client); err != nil {
 		return nil, err
@@ -61,8 +84,7 @@ func NewClientWithIPAndWait(url, ip string) (Client, error) {
 }
 
 func NewClientAndWait(url string) (Client, error) {
-	ip := ""
-	client := newClient(url, ip)
+	client := newClient(url)
 
 	if err := testConnection(client); err != nil {
 		return nil, err
@@ -71,20 +93,96 @@ func NewClientAndWait(url string) (Client, error) {
 	return client, nil
 }
 
+// testConnection waits for the metadata service to become reachable,
+// retrying GetVersion a fixed number of times before giving up.
+func testConnection(m *client) error {
+	var err error
+	for i := 0; i < 10; i++ {
+		if _, err = m.GetVersion(); err == nil {
+			return nil
+		}
+		time.Sleep(2 * time.Second)
+	}
+	return fmt.Errorf("unable to reach metadata service: %v", err)
+}
+
 func (m *client) SendRequest(path string) ([]byte, error) {
-	req, err := http.NewRequest("GET", m.url+path, nil)
-	req.Header.Add("Accept", "application/json")
-	if m.ip != "" {
-		req.Header.Add("X-Forwarded-For", m.ip)
+	ctx := m.baseCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return m.SendRequestWithContext(ctx, path)
+}
+
+// SendRequestWithContext behaves like SendRequest but ties the request, and
+// any retries, to ctx so callers running under cancellation or a deadline
+// can propagate it through the request chain.
+func (m *client) SendRequestWithContext(ctx context.Context, path string) ([]byte, error) {
+	attempts := m.retryPolicy.MaxAttempts
+	if attempts <= 0 {
+		attempts = 1
 	}
-	resp, err := m.client.Do(req)
+
+	var lastErr error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		body, err := m.sendRequestOnce(ctx, path)
+		if err == nil {
+			return body, nil
+		}
+
+		lastErr = err
+		if attempt == attempts || !isRetryable(err) {
+			return nil, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(m.retryPolicy.delay(attempt)):
+		}
+	}
+
+	return nil, lastErr
+}
+
+func (m *client) sendRequestOnce(ctx context.Context, path string) ([]byte, error) {
+	return m.doRequest(ctx, m.client, path)
+}
+
+// doRequest issues a single GET for path against hc, which lets callers such
+// as the long-poll watcher substitute a client with a longer timeout than
+// the package default without otherwise duplicating request setup.
+func (m *client) doRequest(ctx context.Context, hc *http.Client, path string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", m.url+path, nil)
 	if err != nil {
 		return nil, err
 	}
+	req.Header.Set("Accept", "application/json")
+	if m.ip != "" {
+		req.Header.Set("X-Forwarded-For", m.ip)
+	}
+	if m.userAgent != "" {
+		req.Header.Set("User-Agent", m.userAgent)
+	}
+	for key, values := range m.headers {
+		for _, value := range values {
+			req.Header.Add(key, value)
+		}
+	}
+
+	m.observer.RequestStarted(path)
+	start := time.Now()
+
+	resp, err := hc.Do(req)
+	if err != nil {
+		m.observer.RequestCompleted(path, 0, time.Since(start))
+		return nil, classify(err)
+	}
 	defer resp.Body.Close()
+	m.observer.RequestCompleted(path, resp.StatusCode, time.Since(start))
 
 	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("Error %v accessing %v path", resp.StatusCode, path)
+		return nil, classify(&StatusError{StatusCode: resp.StatusCode, Path: path})
 	}
 
 	body, err := ioutil.ReadAll(resp.Body)
@@ -353,7 +451,7 @@ func (m *client) GetHost(UUID string) (Host, error) {
 		}
 	}
 
-	return host, fmt.Errorf("could not find host by UUID %v", UUID)
+	return host, fmt.Errorf("%w: host %v", ErrNotFound, UUID)
 }
 
 func (m *client) GetNetworks() ([]Network, error) {
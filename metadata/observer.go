@@ -0,0 +1,33 @@
+package metadata
+
+import "time"
+
+// Observer receives instrumentation events from a Client, its watcher, and
+// any CachingClient wrapping it. It lets operators export request rate,
+// latency and cache behaviour to a metrics system without this package
+// depending on one directly; see the prometheus subpackage for a
+// ready-made implementation.
+type Observer interface {
+	// RequestStarted is called before a GET is issued for path.
+	RequestStarted(path string)
+	// RequestCompleted is called after a GET for path finishes, with status
+	// 0 if the request failed before a response was received.
+	RequestCompleted(path string, status int, dur time.Duration)
+	// WatchTick is called after each successful poll by a watcher started
+	// via OnChange, OnChangeWithError or OnChangeWithContext.
+	WatchTick(version string, changed bool)
+	// CacheHit is called by a CachingClient when path is served from cache.
+	CacheHit(path string)
+	// CacheMiss is called by a CachingClient when path is not served from cache.
+	CacheMiss(path string)
+}
+
+// NopObserver implements Observer with no-ops. It is the default Observer
+// for a Client or CachingClient that isn't given one explicitly.
+type NopObserver struct{}
+
+func (NopObserver) RequestStarted(path string)                                  {}
+func (NopObserver) RequestCompleted(path string, status int, dur time.Duration) {}
+func (NopObserver) WatchTick(version string, changed bool)                      {}
+func (NopObserver) CacheHit(path string)                                        {}
+func (NopObserver) CacheMiss(path string)                                       {}
@@ -0,0 +1,136 @@
+package metadata
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Option configures a Client built by NewClient.
+type Option func(*client)
+
+// WithHTTPClient overrides the *http.Client used for every request. It takes
+// precedence over WithTimeout, WithTLSConfig and WithProxy regardless of the
+// order the options are given in: those three never mutate hc itself, only
+// a private clone, so the caller's client is safe to keep using elsewhere.
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *client) {
+		c.client = hc
+		c.userClient = true
+	}
+}
+
+// WithTimeout sets the timeout on the client's *http.Client.
+func WithTimeout(d time.Duration) Option {
+	return func(c *client) {
+		c.cloneClientLocked()
+		c.client.Timeout = d
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used for HTTPS requests.
+func WithTLSConfig(cfg *tls.Config) Option {
+	return func(c *client) {
+		c.cloneClientLocked()
+		transport := cloneTransport(c.client.Transport)
+		transport.TLSClientConfig = cfg
+		c.client.Transport = transport
+	}
+}
+
+// WithProxy sets the proxy function used for outgoing requests, as
+// (*http.Transport).Proxy.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(c *client) {
+		c.cloneClientLocked()
+		transport := cloneTransport(c.client.Transport)
+		transport.Proxy = proxy
+		c.client.Transport = transport
+	}
+}
+
+// cloneClientLocked replaces c.client with a shallow copy of itself the
+// first time a client-shaping option (WithTimeout, WithTLSConfig, WithProxy)
+// is applied after WithHTTPClient, so those options never mutate the
+// *http.Client the caller passed to WithHTTPClient in place. Later calls
+// within the same NewClient, and the common case where WithHTTPClient was
+// never used, just mutate c.client directly like before.
+func (c *client) cloneClientLocked() {
+	if !c.userClient {
+		return
+	}
+	clone := *c.client
+	c.client = &clone
+	c.userClient = false
+}
+
+func cloneTransport(rt http.RoundTripper) *http.Transport {
+	if t, ok := rt.(*http.Transport); ok && t != nil {
+		return t.Clone()
+	}
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// WithUserAgent sets the User-Agent header sent with every request.
+func WithUserAgent(ua string) Option {
+	return func(c *client) {
+		c.userAgent = ua
+	}
+}
+
+// WithHeader adds an extra header sent with every request. It can be given
+// more than once to add several headers, or several values for the same key.
+func WithHeader(key, value string) Option {
+	return func(c *client) {
+		c.headers.Add(key, value)
+	}
+}
+
+// WithForwardedFor sets the X-Forwarded-For header, as NewClientWithIPAndWait does.
+func WithForwardedFor(ip string) Option {
+	return func(c *client) {
+		c.ip = ip
+	}
+}
+
+// WithLogger sets the logger used for diagnostic output, such as OnChange
+// logging a re-watch after a failed poll.
+func WithLogger(logger *log.Logger) Option {
+	return func(c *client) {
+		c.logger = logger
+	}
+}
+
+// WithBaseContext sets the context used as the parent for requests issued
+// through SendRequest, and for the watch loop run by OnChange and
+// OnChangeWithError — cancel it to stop a `go client.OnChange(...)`
+// goroutine instead of leaking it. Use SendRequestWithContext or
+// OnChangeWithContext directly when a single request or watch needs its own
+// context instead.
+func WithBaseContext(ctx context.Context) Option {
+	return func(c *client) {
+		c.baseCtx = ctx
+	}
+}
+
+// WithRetryPolicy overrides the retry policy applied to idempotent GETs.
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *client) {
+		c.retryPolicy = p
+	}
+}
+
+// WithObserver registers an Observer to receive request, watch and cache
+// instrumentation events. See the prometheus subpackage for a ready-made
+// implementation.
+func WithObserver(o Observer) Option {
+	return func(c *client) {
+		if o == nil {
+			o = NopObserver{}
+		}
+		c.observer = o
+	}
+}
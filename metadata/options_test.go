@@ -0,0 +1,109 @@
+package metadata_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+)
+
+// TestOptionsSetHeaders verifies that WithUserAgent, WithHeader and
+// WithForwardedFor are applied to every request issued by NewClient.
+func TestOptionsSetHeaders(t *testing.T) {
+	var gotUserAgent, gotForwardedFor, gotExtra string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotUserAgent = r.Header.Get("User-Agent")
+		gotForwardedFor = r.Header.Get("X-Forwarded-For")
+		gotExtra = r.Header.Get("X-Extra")
+		w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL,
+		metadata.WithUserAgent("go-rancher-metadata-test"),
+		metadata.WithForwardedFor("10.0.0.1"),
+		metadata.WithHeader("X-Extra", "yes"),
+	)
+
+	if _, err := client.SendRequest("/version"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+
+	if gotUserAgent != "go-rancher-metadata-test" {
+		t.Errorf("User-Agent = %q, want %q", gotUserAgent, "go-rancher-metadata-test")
+	}
+	if gotForwardedFor != "10.0.0.1" {
+		t.Errorf("X-Forwarded-For = %q, want %q", gotForwardedFor, "10.0.0.1")
+	}
+	if gotExtra != "yes" {
+		t.Errorf("X-Extra = %q, want %q", gotExtra, "yes")
+	}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+// TestWithHTTPClientOverridesDefault verifies that requests go through the
+// *http.Client passed to WithHTTPClient, as documented, rather than the
+// package's own default client.
+func TestWithHTTPClientOverridesDefault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1"))
+	}))
+	defer server.Close()
+
+	var used bool
+	custom := &http.Client{Transport: roundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		used = true
+		return http.DefaultTransport.RoundTrip(r)
+	})}
+
+	client := metadata.NewClient(server.URL, metadata.WithHTTPClient(custom))
+	if _, err := client.SendRequest("/version"); err != nil {
+		t.Fatalf("SendRequest: %v", err)
+	}
+	if !used {
+		t.Error("request did not go through the *http.Client passed to WithHTTPClient")
+	}
+}
+
+// TestWithHTTPClientNotMutatedByOtherOptions verifies that WithTLSConfig,
+// WithProxy and WithTimeout never modify the *http.Client the caller passed
+// to WithHTTPClient in place, regardless of which option is applied first:
+// the caller may be holding onto and reusing that same *http.Client
+// elsewhere in their program.
+func TestWithHTTPClientNotMutatedByOtherOptions(t *testing.T) {
+	custom := &http.Client{Timeout: 7 * time.Second}
+	originalTransport := custom.Transport
+
+	metadata.NewClient("http://example.invalid",
+		metadata.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+		metadata.WithProxy(func(*http.Request) (*url.URL, error) { return nil, nil }),
+		metadata.WithTimeout(3*time.Second),
+		metadata.WithHTTPClient(custom),
+	)
+	if custom.Timeout != 7*time.Second {
+		t.Errorf("WithHTTPClient applied after other options: Timeout = %v, want unchanged 7s", custom.Timeout)
+	}
+	if custom.Transport != originalTransport {
+		t.Error("WithHTTPClient applied after other options: Transport was mutated")
+	}
+
+	metadata.NewClient("http://example.invalid",
+		metadata.WithHTTPClient(custom),
+		metadata.WithTLSConfig(&tls.Config{InsecureSkipVerify: true}),
+		metadata.WithProxy(func(*http.Request) (*url.URL, error) { return nil, nil }),
+		metadata.WithTimeout(3*time.Second),
+	)
+	if custom.Timeout != 7*time.Second {
+		t.Errorf("WithHTTPClient applied before other options: Timeout = %v, want unchanged 7s", custom.Timeout)
+	}
+	if custom.Transport != originalTransport {
+		t.Error("WithHTTPClient applied before other options: Transport was mutated")
+	}
+}
@@ -0,0 +1,68 @@
+package metadata
+
+// Host represents a Rancher host, as served at /self/host, /hosts and
+// /hosts/<uuid>.
+type Host struct {
+	UUID             string            `json:"uuid"`
+	Name             string            `json:"name"`
+	HostnameOverride string            `json:"hostname_override"`
+	AgentIP          string            `json:"agent_ip"`
+	Labels           map[string]string `json:"labels"`
+}
+
+// Container represents a Rancher container, as served at /self/container,
+// /containers and /containers/<uuid>.
+type Container struct {
+	UUID        string            `json:"uuid"`
+	Name        string            `json:"name"`
+	State       string            `json:"state"`
+	HealthState string            `json:"health_state"`
+	HostUUID    string            `json:"host_uuid"`
+	StackName   string            `json:"stack_name"`
+	ServiceName string            `json:"service_name"`
+	PrimaryIp   string            `json:"primary_ip"`
+	Ips         []string          `json:"ips"`
+	Ports       []string          `json:"ports"`
+	Labels      map[string]string `json:"labels"`
+	CreateIndex int               `json:"create_index"`
+}
+
+// Service represents a Rancher service, as served at /self/service,
+// /services and /stacks/<stack>/services/<name>.
+type Service struct {
+	Name       string                 `json:"name"`
+	StackName  string                 `json:"stack_name"`
+	Kind       string                 `json:"kind"`
+	State      string                 `json:"state"`
+	Vip        string                 `json:"vip"`
+	Fqdn       string                 `json:"fqdn"`
+	Scale      int                    `json:"scale"`
+	Containers []string               `json:"containers"`
+	Sidekicks  []string               `json:"sidekicks"`
+	Ports      []string               `json:"ports"`
+	Labels     map[string]string      `json:"labels"`
+	Metadata   map[string]interface{} `json:"metadata"`
+}
+
+// Stack represents a Rancher stack, as served at /self/stack, /stacks and
+// /stacks/<name>.
+type Stack struct {
+	UUID     string   `json:"uuid"`
+	Name     string   `json:"name"`
+	State    string   `json:"state"`
+	Services []string `json:"services"`
+}
+
+// Network represents a Rancher network, as served at /networks.
+type Network struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// Environment groups the services deployed in a Rancher environment/region,
+// as served at /environments.
+type Environment struct {
+	Name       string    `json:"name"`
+	RegionName string    `json:"region_name"`
+	Services   []Service `json:"services"`
+}
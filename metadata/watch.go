@@ -0,0 +1,228 @@
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+// WatchMode selects how OnChangeWithContext polls the metadata service for
+// version changes.
+type WatchMode int
+
+const (
+	// LongPoll issues GET /version?wait=true&value=<lastVersion>&maxWait=<N>
+	// and relies on the metadata service to hold the connection open until
+	// the version changes or maxWait elapses.
+	LongPoll WatchMode = iota
+	// Interval polls GET /version on a fixed schedule.
+	Interval
+)
+
+// BackoffOptions controls the delay between retries after a failed poll.
+type BackoffOptions struct {
+	Base   time.Duration
+	Max    time.Duration
+	Factor float64
+}
+
+func defaultBackoff() BackoffOptions {
+	return BackoffOptions{
+		Base:   time.Second,
+		Max:    30 * time.Second,
+		Factor: 2.0,
+	}
+}
+
+func (b BackoffOptions) delay(attempt int) time.Duration {
+	base := b.Base
+	if base <= 0 {
+		base = time.Second
+	}
+	max := b.Max
+	if max <= 0 {
+		max = 30 * time.Second
+	}
+	factor := b.Factor
+	if factor <= 0 {
+		factor = 2.0
+	}
+
+	d := float64(base) * math.Pow(factor, float64(attempt-1))
+	if d > float64(max) {
+		d = float64(max)
+	}
+	// jitter in [0.5*d, 1.0*d] to avoid synchronized retries across callers.
+	return time.Duration(d * (0.5 + rand.Float64()*0.5))
+}
+
+// WatchOptions configures OnChangeWithContext.
+type WatchOptions struct {
+	Mode     WatchMode
+	MaxWait  time.Duration
+	Interval time.Duration
+	Backoff  BackoffOptions
+}
+
+func (o WatchOptions) withDefaults() WatchOptions {
+	if o.MaxWait <= 0 {
+		o.MaxWait = 60 * time.Second
+	}
+	if o.Interval <= 0 {
+		o.Interval = 5 * time.Second
+	}
+	if o.Backoff == (BackoffOptions{}) {
+		o.Backoff = defaultBackoff()
+	}
+	return o
+}
+
+// ChangeEvent is delivered to the OnChangeWithContext callback whenever the
+// metadata version changes.
+type ChangeEvent struct {
+	LastVersion string
+	Version     string
+}
+
+// OnChangeWithContext watches the metadata version using either a long-poll
+// or a fixed-interval strategy, invoking do every time the version changes.
+// It blocks until ctx is cancelled, recovering from timeouts, 5xx errors and
+// connection resets with exponential backoff.
+func (m *client) OnChangeWithContext(ctx context.Context, opts WatchOptions, do func(ChangeEvent)) error {
+	opts = opts.withDefaults()
+
+	lastVersion := "0"
+	attempt := 0
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		default:
+		}
+
+		var version string
+		var err error
+		if opts.Mode == Interval {
+			version, err = m.GetVersion()
+		} else {
+			version, err = m.getVersionWithWait(ctx, lastVersion, opts.MaxWait)
+		}
+
+		if err != nil {
+			attempt++
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.Backoff.delay(attempt)):
+			}
+			continue
+		}
+		attempt = 0
+
+		changed := version != lastVersion
+		m.observer.WatchTick(version, changed)
+		if changed {
+			do(ChangeEvent{LastVersion: lastVersion, Version: version})
+			lastVersion = version
+		}
+
+		if opts.Mode == Interval {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+	}
+}
+
+// getVersionWithWait issues a long-poll GET /version that the metadata
+// service may legitimately hold open for up to maxWait. It uses a client
+// whose timeout covers maxWait instead of m.client directly, since
+// m.client's own default 10-second Timeout would otherwise abort every poll
+// that outlives it, turning LongPoll mode into a busy retry loop.
+func (m *client) getVersionWithWait(ctx context.Context, lastVersion string, maxWait time.Duration) (string, error) {
+	seconds := int(maxWait / time.Second)
+	if seconds <= 0 {
+		seconds = 1
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, maxWait+10*time.Second)
+	defer cancel()
+
+	resp, err := m.doRequest(waitCtx, m.longPollClient(maxWait), fmt.Sprintf("/version?wait=true&value=%s&maxWait=%d", lastVersion, seconds))
+	if err != nil {
+		return "", err
+	}
+	return string(resp), nil
+}
+
+// longPollClient returns an *http.Client suitable for a single long-poll
+// request: m.client itself if its Timeout already covers maxWait, otherwise
+// a clone with Timeout extended to cover it.
+func (m *client) longPollClient(maxWait time.Duration) *http.Client {
+	needed := maxWait + 10*time.Second
+	if m.client.Timeout == 0 || m.client.Timeout >= needed {
+		return m.client
+	}
+	clone := *m.client
+	clone.Timeout = needed
+	return &clone
+}
+
+// OnChangeWithError keeps its original signature for backward compatibility:
+// intervalSeconds is interpreted as the long-poll maxWait, in seconds. It
+// runs until m.baseCtx (set via WithBaseContext) is cancelled, so a caller
+// that wants to stop a `go client.OnChange(...)`/`go client.OnChangeWithError(...)`
+// goroutine should build the Client with WithBaseContext and cancel that
+// context instead of leaking it for the life of the process.
+func (m *client) OnChangeWithError(intervalSeconds int, do func(string)) error {
+	return m.OnChangeWithContext(m.watchBaseContext(), WatchOptions{
+		Mode:    LongPoll,
+		MaxWait: time.Duration(intervalSeconds) * time.Second,
+	}, func(ev ChangeEvent) {
+		do(ev.Version)
+	})
+}
+
+// OnChange keeps its original signature for backward compatibility: it
+// re-watches after logging any error from OnChangeWithError, until
+// m.baseCtx (set via WithBaseContext) is cancelled. See OnChangeWithError.
+func (m *client) OnChange(intervalSeconds int, do func(string)) {
+	ctx := m.watchBaseContext()
+	for {
+		if err := m.OnChangeWithError(intervalSeconds, do); err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			m.logf("Error watching metadata version, re-watching: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(time.Duration(intervalSeconds) * time.Second):
+		}
+	}
+}
+
+// watchBaseContext returns m.baseCtx, falling back to context.Background()
+// when none was set via WithBaseContext.
+func (m *client) watchBaseContext() context.Context {
+	if m.baseCtx != nil {
+		return m.baseCtx
+	}
+	return context.Background()
+}
+
+func (m *client) logf(format string, args ...interface{}) {
+	if m.logger != nil {
+		m.logger.Printf(format, args...)
+		return
+	}
+	log.Printf(format, args...)
+}
@@ -0,0 +1,136 @@
+package metadata_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+	"github.com/leodotcloud/go-rancher-metadata/metadatatest"
+)
+
+// TestOnChangeWithContextLongPollOutlivesHTTPTimeout guards against the
+// long-poll request being aborted by the client's short default HTTP
+// timeout before the fake server's long-poll wait (longer than that
+// timeout) elapses.
+func TestOnChangeWithContextLongPollOutlivesHTTPTimeout(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{Version: "1"})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL(), metadata.WithTimeout(50*time.Millisecond))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- client.OnChangeWithContext(ctx, metadata.WatchOptions{
+			Mode:    metadata.LongPoll,
+			MaxWait: 200 * time.Millisecond,
+		}, func(metadata.ChangeEvent) {})
+	}()
+
+	time.Sleep(300 * time.Millisecond)
+	server.Publish(metadatatest.Snapshot{Version: "2"})
+
+	select {
+	case err := <-done:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("OnChangeWithContext returned %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnChangeWithContext never returned after ctx was cancelled")
+	}
+}
+
+// TestOnChangeStopsWithBaseContext verifies that cancelling the context set
+// via WithBaseContext stops a goroutine started with OnChange, instead of
+// leaking it for the life of the process.
+func TestOnChangeStopsWithBaseContext(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{Version: "1"})
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	client := metadata.NewClient(server.URL(), metadata.WithBaseContext(ctx))
+
+	var calls int32
+	stopped := make(chan struct{})
+	go func() {
+		client.OnChange(1, func(string) {
+			atomic.AddInt32(&calls, 1)
+		})
+		close(stopped)
+	}()
+
+	server.Publish(metadatatest.Snapshot{Version: "2"})
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+
+	select {
+	case <-stopped:
+	case <-time.After(3 * time.Second):
+		t.Fatal("OnChange goroutine did not stop after its base context was cancelled")
+	}
+
+	if atomic.LoadInt32(&calls) == 0 {
+		t.Fatal("OnChange callback was never invoked before the context was cancelled")
+	}
+}
+
+// TestOnChangeWithContextIntervalMode verifies that WatchMode.Interval polls
+// GET /version on a fixed schedule and still delivers a ChangeEvent for
+// every version change, the same as LongPoll mode does.
+func TestOnChangeWithContextIntervalMode(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{Version: "1"})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events := make(chan metadata.ChangeEvent, 2)
+	done := make(chan error, 1)
+	go func() {
+		done <- client.OnChangeWithContext(ctx, metadata.WatchOptions{
+			Mode:     metadata.Interval,
+			Interval: 10 * time.Millisecond,
+		}, func(ev metadata.ChangeEvent) {
+			events <- ev
+		})
+	}()
+
+	// The first poll always fires a ChangeEvent, since OnChangeWithContext
+	// starts from the zero-value "last seen" version; drain it before
+	// publishing the version change this test actually cares about.
+	select {
+	case ev := <-events:
+		if ev.Version != "1" {
+			t.Fatalf("initial ChangeEvent = %+v, want Version 1", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Interval mode never delivered its initial ChangeEvent")
+	}
+
+	server.Publish(metadatatest.Snapshot{Version: "2"})
+
+	select {
+	case ev := <-events:
+		if ev.LastVersion != "1" || ev.Version != "2" {
+			t.Fatalf("ChangeEvent = %+v, want {LastVersion:1 Version:2}", ev)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Interval mode never polled the version change")
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		if err != context.Canceled {
+			t.Fatalf("OnChangeWithContext returned %v, want context.Canceled", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("OnChangeWithContext never returned after ctx was cancelled")
+	}
+}
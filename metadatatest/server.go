@@ -0,0 +1,233 @@
+// Package metadatatest provides an in-process fake of the Rancher metadata
+// service so that consumers of the metadata package can unit-test their
+// integration without a real Rancher agent.
+package metadatatest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+)
+
+// Self groups the /self/... responses served by a Server.
+type Self struct {
+	Host      metadata.Host      `json:"host" yaml:"host"`
+	Container metadata.Container `json:"container" yaml:"container"`
+	Service   metadata.Service   `json:"service" yaml:"service"`
+	Stack     metadata.Stack     `json:"stack" yaml:"stack"`
+}
+
+// Snapshot is a point-in-time capture of everything the metadata service
+// would serve. Load one from a fixture with LoadSnapshotFile and hand it to
+// NewServer, or build one by hand in a test.
+type Snapshot struct {
+	Version      string                 `json:"version" yaml:"version"`
+	RegionName   string                 `json:"region_name" yaml:"region_name"`
+	Self         Self                   `json:"self" yaml:"self"`
+	Stacks       []metadata.Stack       `json:"stacks" yaml:"stacks"`
+	Services     []metadata.Service     `json:"services" yaml:"services"`
+	Containers   []metadata.Container   `json:"containers" yaml:"containers"`
+	Hosts        []metadata.Host        `json:"hosts" yaml:"hosts"`
+	Networks     []metadata.Network     `json:"networks" yaml:"networks"`
+	Environments []metadata.Environment `json:"environments" yaml:"environments"`
+}
+
+// LoadSnapshotFile reads a Snapshot from a YAML or JSON fixture, the format
+// chosen by the file extension (.yaml, .yml or .json).
+func LoadSnapshotFile(path string) (Snapshot, error) {
+	var snapshot Snapshot
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return snapshot, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &snapshot)
+	default:
+		err = json.Unmarshal(data, &snapshot)
+	}
+
+	return snapshot, err
+}
+
+// Server is an in-process fake of the Rancher metadata service, serving the
+// same routes SendRequest hits against a real agent.
+type Server struct {
+	httpServer *httptest.Server
+
+	mu       sync.Mutex
+	snapshot Snapshot
+	waiters  map[chan struct{}]struct{}
+}
+
+// NewServer starts a Server seeded with fixture and returns it ready to use;
+// callers should Close it once done.
+func NewServer(fixture Snapshot) *Server {
+	s := &Server{
+		snapshot: fixture,
+		waiters:  map[chan struct{}]struct{}{},
+	}
+	s.httpServer = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// URL is the base URL a metadata.Client should be pointed at.
+func (s *Server) URL() string {
+	return s.httpServer.URL
+}
+
+// Close shuts down the underlying httptest.Server.
+func (s *Server) Close() {
+	s.httpServer.Close()
+}
+
+// Publish replaces the served snapshot and unblocks any long-poll waiters,
+// mirroring how the real metadata service bumps /version on a config change.
+func (s *Server) Publish(snapshot Snapshot) {
+	s.mu.Lock()
+	s.snapshot = snapshot
+	waiters := s.waiters
+	s.waiters = map[chan struct{}]struct{}{}
+	s.mu.Unlock()
+
+	for ch := range waiters {
+		close(ch)
+	}
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	path := strings.TrimSuffix(r.URL.Path, "/")
+
+	switch {
+	case path == "/version":
+		s.handleVersion(w, r)
+	case path == "/region_name":
+		s.writeQuotedString(w, s.snapshot.RegionName)
+	case path == "/self/host":
+		s.writeJSON(w, s.snapshot.Self.Host)
+	case path == "/self/container":
+		s.writeJSON(w, s.snapshot.Self.Container)
+	case path == "/self/service":
+		s.writeJSON(w, s.snapshot.Self.Service)
+	case path == "/self/stack":
+		s.writeJSON(w, s.snapshot.Self.Stack)
+	case strings.HasPrefix(path, "/self/stack/services/"):
+		name := strings.TrimPrefix(path, "/self/stack/services/")
+		s.writeService(w, s.snapshot.Self.Stack.Name, name)
+	case path == "/stacks":
+		s.writeJSON(w, s.snapshot.Stacks)
+	case path == "/services":
+		s.writeJSON(w, s.snapshot.Services)
+	case path == "/containers":
+		s.writeJSON(w, s.snapshot.Containers)
+	case path == "/hosts":
+		s.writeJSON(w, s.snapshot.Hosts)
+	case path == "/networks":
+		s.writeJSON(w, s.snapshot.Networks)
+	case path == "/environments":
+		s.writeJSON(w, s.snapshot.Environments)
+	case strings.HasPrefix(path, "/stacks/"):
+		rest := strings.TrimPrefix(path, "/stacks/")
+		if idx := strings.Index(rest, "/services/"); idx >= 0 {
+			s.writeService(w, rest[:idx], rest[idx+len("/services/"):])
+			return
+		}
+		s.writeStack(w, rest)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) handleVersion(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("wait") != "true" {
+		s.mu.Lock()
+		version := s.snapshot.Version
+		s.mu.Unlock()
+		fmt.Fprint(w, version)
+		return
+	}
+
+	lastVersion := r.URL.Query().Get("value")
+	maxWait := parseMaxWait(r.URL.Query().Get("maxWait"))
+
+	s.mu.Lock()
+	if s.snapshot.Version == lastVersion {
+		ch := make(chan struct{})
+		s.waiters[ch] = struct{}{}
+		s.mu.Unlock()
+
+		select {
+		case <-ch:
+		case <-time.After(maxWait):
+		}
+
+		s.mu.Lock()
+		delete(s.waiters, ch)
+	}
+	version := s.snapshot.Version
+	s.mu.Unlock()
+
+	fmt.Fprint(w, version)
+}
+
+func (s *Server) writeStack(w http.ResponseWriter, name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, stack := range s.snapshot.Stacks {
+		if stack.Name == name {
+			s.writeJSON(w, stack)
+			return
+		}
+	}
+	http.NotFound(w, nil)
+}
+
+func (s *Server) writeService(w http.ResponseWriter, stackName, svcName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, svc := range s.snapshot.Services {
+		if svc.StackName == stackName && svc.Name == svcName {
+			s.writeJSON(w, svc)
+			return
+		}
+	}
+	http.NotFound(w, nil)
+}
+
+func (s *Server) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeQuotedString writes v as a bare JSON string with no trailing
+// newline, matching the real metadata service's /region_name response.
+// json.NewEncoder always appends "\n" after the value it writes, which
+// metadata.GetRegionName's TrimSuffix(s, "\"") doesn't account for, so
+// writeJSON can't be reused here.
+func (s *Server) writeQuotedString(w http.ResponseWriter, v string) {
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, "%q", v)
+}
+
+func parseMaxWait(raw string) time.Duration {
+	seconds, err := strconv.Atoi(raw)
+	if err != nil || seconds <= 0 {
+		return 60 * time.Second
+	}
+	return time.Duration(seconds) * time.Second
+}
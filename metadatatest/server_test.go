@@ -0,0 +1,55 @@
+package metadatatest_test
+
+import (
+	"testing"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+	"github.com/leodotcloud/go-rancher-metadata/metadatatest"
+)
+
+// TestGetRegionName guards against writeJSON's trailing newline leaking
+// into GetRegionName's result: metadata.GetRegionName only trims a
+// surrounding '"', so a "\n" written after it would survive into the
+// returned region name.
+func TestGetRegionName(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{RegionName: "us-west"})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL())
+
+	got, err := client.GetRegionName()
+	if err != nil {
+		t.Fatalf("GetRegionName: %v", err)
+	}
+	if got != "us-west" {
+		t.Fatalf("GetRegionName = %q, want %q", got, "us-west")
+	}
+}
+
+// TestGetServiceByEnvironment exercises the lookup chain that depends on
+// GetRegionName matching Environment.RegionName exactly.
+func TestGetServiceByEnvironment(t *testing.T) {
+	server := metadatatest.NewServer(metadatatest.Snapshot{
+		RegionName: "us-west",
+		Environments: []metadata.Environment{
+			{
+				Name:       "production",
+				RegionName: "us-west",
+				Services: []metadata.Service{
+					{Name: "web", StackName: "app"},
+				},
+			},
+		},
+	})
+	defer server.Close()
+
+	client := metadata.NewClient(server.URL())
+
+	svc, err := client.GetServiceByEnvironment("production", "app", "web")
+	if err != nil {
+		t.Fatalf("GetServiceByEnvironment: %v", err)
+	}
+	if svc.Name != "web" {
+		t.Fatalf("GetServiceByEnvironment returned service %q, want %q", svc.Name, "web")
+	}
+}
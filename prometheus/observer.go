@@ -0,0 +1,87 @@
+// Package prometheus implements metadata.Observer with Prometheus
+// CounterVec/HistogramVec metrics, so operators can export metadata
+// request rate, latency, watch and cache behaviour without the metadata
+// package itself taking a dependency on client_golang.
+package prometheus
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/leodotcloud/go-rancher-metadata/metadata"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ metadata.Observer = (*Observer)(nil)
+
+// Observer implements metadata.Observer by recording events to a set of
+// Prometheus metrics registered under namespace/subsystem. Pass it to
+// metadata.WithObserver and/or metadata.CacheOptions.Observer.
+type Observer struct {
+	requestsTotal   *prometheus.CounterVec
+	requestDuration *prometheus.HistogramVec
+	watchTicksTotal *prometheus.CounterVec
+	cacheResults    *prometheus.CounterVec
+}
+
+// NewObserver builds an Observer and registers its metrics with reg (use
+// prometheus.DefaultRegisterer for the global registry). namespace and
+// subsystem are used as in prometheus.Opts; either may be empty.
+func NewObserver(reg prometheus.Registerer, namespace, subsystem string) *Observer {
+	o := &Observer{
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "requests_total",
+			Help:      "Total metadata service requests, by path and status code (0 for a failed request).",
+		}, []string{"path", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "request_duration_seconds",
+			Help:      "Metadata service request latency in seconds, by path.",
+		}, []string{"path"}),
+		watchTicksTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "watch_ticks_total",
+			Help:      "Total watch polls, by whether the metadata version changed.",
+		}, []string{"changed"}),
+		cacheResults: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: subsystem,
+			Name:      "cache_results_total",
+			Help:      "Total CachingClient lookups, by path and whether they hit or missed.",
+		}, []string{"path", "result"}),
+	}
+
+	reg.MustRegister(o.requestsTotal, o.requestDuration, o.watchTicksTotal, o.cacheResults)
+
+	return o
+}
+
+// RequestStarted is a no-op: request rate is derived from RequestCompleted
+// so a failed request is still counted exactly once.
+func (o *Observer) RequestStarted(path string) {}
+
+// RequestCompleted records the request's outcome and latency.
+func (o *Observer) RequestCompleted(path string, status int, dur time.Duration) {
+	o.requestsTotal.WithLabelValues(path, strconv.Itoa(status)).Inc()
+	o.requestDuration.WithLabelValues(path).Observe(dur.Seconds())
+}
+
+// WatchTick records a watch poll, labeled by whether it observed a version change.
+func (o *Observer) WatchTick(version string, changed bool) {
+	o.watchTicksTotal.WithLabelValues(strconv.FormatBool(changed)).Inc()
+}
+
+// CacheHit records a CachingClient lookup served from cache.
+func (o *Observer) CacheHit(path string) {
+	o.cacheResults.WithLabelValues(path, "hit").Inc()
+}
+
+// CacheMiss records a CachingClient lookup that had to fetch from the
+// wrapped Client.
+func (o *Observer) CacheMiss(path string) {
+	o.cacheResults.WithLabelValues(path, "miss").Inc()
+}
@@ -0,0 +1,41 @@
+package prometheus_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	rancherprometheus "github.com/leodotcloud/go-rancher-metadata/prometheus"
+)
+
+func TestObserverRecordsRequestsAndCache(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	o := rancherprometheus.NewObserver(reg, "rancher", "metadata")
+
+	o.RequestCompleted("/version", 200, 10*time.Millisecond)
+	o.CacheHit("/stacks")
+	o.CacheMiss("/stacks")
+	o.WatchTick("2", true)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	counts := map[string]int{}
+	for _, mf := range families {
+		counts[mf.GetName()] = len(mf.GetMetric())
+	}
+
+	for _, name := range []string{
+		"rancher_metadata_requests_total",
+		"rancher_metadata_request_duration_seconds",
+		"rancher_metadata_watch_ticks_total",
+		"rancher_metadata_cache_results_total",
+	} {
+		if counts[name] == 0 {
+			t.Errorf("metric %s was not registered or recorded", name)
+		}
+	}
+}